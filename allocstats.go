@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/olekukonko/tablewriter"
+)
+
+// allocStatsCommand displays CPU/memory usage for running allocations,
+// sourced from each allocation's node-local client API.
+type allocStatsCommand struct {
+	client     *nomad.Client
+	nomadTasks []nomadTask
+	interval   time.Duration
+	once       bool
+	jsonOutput bool
+}
+
+func NewAllocStatsCommand(client *nomad.Client, interval time.Duration, once, jsonOutput bool, filters []string) (*allocStatsCommand, error) {
+	nomadTasks, err := parseNomadTasks(filters)
+	if err != nil {
+		return nil, err
+	}
+	return &allocStatsCommand{client, nomadTasks, interval, once, jsonOutput}, nil
+}
+
+func (a *allocStatsCommand) matches(jobID, task string) bool {
+	if len(a.nomadTasks) == 0 {
+		return true
+	}
+	for _, t := range a.nomadTasks {
+		if t.task != task {
+			continue
+		}
+		if t.job == "" || t.job == jobID {
+			return true
+		}
+	}
+	return false
+}
+
+type statsRow struct {
+	Allocation     string  `json:"allocation"`
+	JobID          string  `json:"job_id"`
+	Task           string  `json:"task"`
+	CPUPercent     float64 `json:"cpu_percent"`
+	RSSBytes       uint64  `json:"rss_bytes"`
+	CacheBytes     uint64  `json:"cache_bytes"`
+	ThrottledNanos uint64  `json:"throttled_nanos"`
+}
+
+func (a *allocStatsCommand) Run() error {
+	for {
+		rows, err := a.collect()
+		if err != nil {
+			log.Printf("could not collect allocation stats: %s", err)
+		} else if a.jsonOutput {
+			a.renderJSON(rows)
+		} else {
+			a.renderTable(rows)
+		}
+
+		if a.once {
+			return nil
+		}
+		time.Sleep(a.interval)
+	}
+}
+
+func (a *allocStatsCommand) collect() ([]statsRow, error) {
+	list, _, err := a.client.Allocations().List(nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not list nomad allocations: %w", err)
+	}
+
+	var rows []statsRow
+	for _, allocStub := range list {
+		if allocStub.ClientStatus != "running" {
+			continue
+		}
+
+		var matchedTasks []string
+		for task := range allocStub.TaskStates {
+			if a.matches(allocStub.JobID, task) {
+				matchedTasks = append(matchedTasks, task)
+			}
+		}
+		if len(matchedTasks) == 0 {
+			continue
+		}
+
+		nodeClient, err := a.client.GetNodeClient(allocStub.NodeID, nil)
+		if err != nil {
+			log.Printf("%s: could not reach node %s: %s", allocStub.ID[:8], allocStub.NodeID, err)
+			continue
+		}
+
+		alloc, _, err := a.client.Allocations().Info(allocStub.ID, nil)
+		if err != nil {
+			log.Printf("%s: could not retrieve allocation: %s", allocStub.ID[:8], err)
+			continue
+		}
+
+		usage, err := nodeClient.Allocations().Stats(alloc, nil)
+		if err != nil {
+			log.Printf("%s: could not retrieve stats: %s", allocStub.ID[:8], err)
+			continue
+		}
+
+		for _, task := range matchedTasks {
+			taskUsage, ok := usage.Tasks[task]
+			if !ok || taskUsage.ResourceUsage == nil {
+				continue
+			}
+			row := statsRow{
+				Allocation: allocStub.ID[:8],
+				JobID:      allocStub.JobID,
+				Task:       task,
+			}
+			if cpu := taskUsage.ResourceUsage.CpuStats; cpu != nil {
+				row.CPUPercent = cpu.Percent
+				row.ThrottledNanos = cpu.ThrottledTime
+			}
+			if mem := taskUsage.ResourceUsage.MemoryStats; mem != nil {
+				row.RSSBytes = mem.RSS
+				row.CacheBytes = mem.Cache
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func (a *allocStatsCommand) renderJSON(rows []statsRow) {
+	enc := json.NewEncoder(os.Stdout)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			log.Printf("could not encode row: %s", err)
+		}
+	}
+}
+
+func (a *allocStatsCommand) renderTable(rows []statsRow) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Allocation", "Job ID", "Task", "CPU %", "RSS", "Cache", "Throttled"})
+	for _, row := range rows {
+		table.Append([]string{
+			row.Allocation,
+			row.JobID,
+			row.Task,
+			fmt.Sprintf("%.2f", row.CPUPercent),
+			humanBytes(row.RSSBytes),
+			humanBytes(row.CacheBytes),
+			time.Duration(row.ThrottledNanos).String(),
+		})
+	}
+	table.Render()
+}
+
+func humanBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
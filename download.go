@@ -0,0 +1,322 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+)
+
+// downloadCommand snapshots the full stdout/stderr history of every
+// allocation matching a job:task to disk, optionally following for new
+// output as it's produced.
+type downloadCommand struct {
+	client     *nomad.Client
+	nomadTasks []nomadTask
+	outputDir  string
+	gzip       bool
+	maxSize    int64
+	since      time.Duration
+	follow     bool
+}
+
+func NewDownloadCommand(client *nomad.Client, outputDir string, gzip bool, maxSize int64, since time.Duration, follow bool, tasks []string) (*downloadCommand, error) {
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("no tasks specified")
+	}
+	nomadTasks, err := parseNomadTasks(tasks)
+	if err != nil {
+		return nil, err
+	}
+	return &downloadCommand{client, nomadTasks, outputDir, gzip, maxSize, since, follow}, nil
+}
+
+func (d *downloadCommand) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, task := range d.nomadTasks {
+		wg.Add(1)
+		go func(task nomadTask) {
+			defer wg.Done()
+			d.watchTask(ctx, task)
+		}(task)
+	}
+	wg.Wait()
+	return nil
+}
+
+// watchTask polls for allocations matching task and downloads each one it
+// hasn't seen yet, re-polling every pollInterval while d.follow is set, until
+// ctx is cancelled.
+func (d *downloadCommand) watchTask(ctx context.Context, task nomadTask) {
+	const pollInterval = 5 * time.Second
+
+	seen := make(map[string]struct{})
+	var cutoff time.Time
+	if d.since > 0 {
+		cutoff = time.Now().Add(-d.since)
+	}
+
+	var wg sync.WaitGroup
+loop:
+	for {
+		list, _, err := d.client.Allocations().List(nil)
+		if err != nil {
+			log.Printf("could not list nomad allocations: %s", err)
+		}
+
+		for _, allocStub := range list {
+			if _, ok := allocStub.TaskStates[task.task]; !ok {
+				continue
+			}
+			if task.job != "" && task.job != allocStub.JobID {
+				continue
+			}
+			if _, ok := seen[allocStub.ID]; ok {
+				continue
+			}
+			if !cutoff.IsZero() && time.Unix(0, allocStub.CreateTime).Before(cutoff) {
+				continue
+			}
+			seen[allocStub.ID] = struct{}{}
+
+			wg.Add(1)
+			go func(allocStub *nomad.AllocationListStub) {
+				defer wg.Done()
+				if err := d.downloadAllocation(ctx, allocStub.ID, task.task); err != nil {
+					log.Printf("%s:%s: %s: %s", task.job, task.task, allocStub.ID[:8], err)
+				}
+			}(allocStub)
+		}
+
+		if !d.follow {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-time.After(pollInterval):
+		}
+	}
+	wg.Wait()
+}
+
+// allocManifest is written alongside the downloaded logs so allocation
+// metadata can be correlated after the fact.
+type allocManifest struct {
+	JobID  string             `json:"job_id"`
+	Task   string             `json:"task"`
+	NodeID string             `json:"node_id"`
+	Events []*nomad.TaskEvent `json:"events"`
+}
+
+func (d *downloadCommand) downloadAllocation(ctx context.Context, allocID, task string) error {
+	alloc, _, err := d.client.Allocations().Info(allocID, nil)
+	if err != nil {
+		return fmt.Errorf("could not retrieve allocation: %w", err)
+	}
+
+	dir := filepath.Join(d.outputDir, alloc.JobID, alloc.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("could not create output dir: %w", err)
+	}
+
+	if err := d.writeManifest(dir, alloc, task); err != nil {
+		return fmt.Errorf("could not write manifest: %w", err)
+	}
+
+	stdout, err := newRotatingWriter(filepath.Join(dir, task+".stdout.log"), d.gzip, d.maxSize)
+	if err != nil {
+		return fmt.Errorf("could not open stdout log: %w", err)
+	}
+	defer stdout.Close()
+
+	stderr, err := newRotatingWriter(filepath.Join(dir, task+".stderr.log"), d.gzip, d.maxSize)
+	if err != nil {
+		return fmt.Errorf("could not open stderr log: %w", err)
+	}
+	defer stderr.Close()
+
+	stdoutFrames, stdoutErrCh := d.client.AllocFS().Logs(alloc, d.follow, task, "stdout", "start", 0, nil, nil)
+	stderrFrames, stderrErrCh := d.client.AllocFS().Logs(alloc, d.follow, task, "stderr", "start", 0, nil, nil)
+
+	for stdoutFrames != nil || stderrFrames != nil {
+		select {
+		case <-ctx.Done():
+			return nil
+		case frame, more := <-stdoutFrames:
+			if !more {
+				stdoutFrames = nil
+				continue
+			}
+			if _, err := stdout.Write(frame.Data); err != nil {
+				return fmt.Errorf("could not write stdout: %w", err)
+			}
+		case frame, more := <-stderrFrames:
+			if !more {
+				stderrFrames = nil
+				continue
+			}
+			if _, err := stderr.Write(frame.Data); err != nil {
+				return fmt.Errorf("could not write stderr: %w", err)
+			}
+		case err := <-stdoutErrCh:
+			if err != nil && !strings.Contains(err.Error(), "unknown task name") {
+				log.Printf("%s: stdout stream error (allocation probably shutting down): %s", alloc.ID[:8], err)
+			}
+			stdoutFrames = nil
+		case err := <-stderrErrCh:
+			if err != nil && !strings.Contains(err.Error(), "unknown task name") {
+				log.Printf("%s: stderr stream error (allocation probably shutting down): %s", alloc.ID[:8], err)
+			}
+			stderrFrames = nil
+		}
+	}
+
+	return nil
+}
+
+func (d *downloadCommand) writeManifest(dir string, alloc *nomad.Allocation, task string) error {
+	state := alloc.TaskStates[task]
+	var events []*nomad.TaskEvent
+	if state != nil {
+		events = state.Events
+	}
+	manifest := allocManifest{
+		JobID:  alloc.JobID,
+		Task:   task,
+		NodeID: alloc.NodeID,
+		Events: events,
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "manifest.json"), data, 0o644)
+}
+
+// rotatingWriter is an io.WriteCloser that rotates the underlying file once
+// it crosses maxSize bytes (when maxSize > 0), optionally gzip-compressing
+// each file as it's written.
+type rotatingWriter struct {
+	path       string
+	gzip       bool
+	maxSize    int64
+	generation int
+	written    int64
+	file       *os.File
+	gz         *gzip.Writer
+}
+
+func newRotatingWriter(path string, useGzip bool, maxSize int64) (*rotatingWriter, error) {
+	w := &rotatingWriter{path: path, gzip: useGzip, maxSize: maxSize}
+	if err := w.openCurrent(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) currentPath() string {
+	if w.generation == 0 {
+		if w.gzip {
+			return w.path + ".gz"
+		}
+		return w.path
+	}
+	if w.gzip {
+		return fmt.Sprintf("%s.%d.gz", w.path, w.generation)
+	}
+	return fmt.Sprintf("%s.%d", w.path, w.generation)
+}
+
+func (w *rotatingWriter) openCurrent() error {
+	f, err := os.OpenFile(w.currentPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.written = 0
+	if w.gzip {
+		w.gz = gzip.NewWriter(f)
+	}
+	return nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	if w.maxSize > 0 && w.written >= w.maxSize {
+		if err := w.closeCurrent(); err != nil {
+			return 0, err
+		}
+		w.generation++
+		if err := w.openCurrent(); err != nil {
+			return 0, err
+		}
+	}
+
+	var n int
+	var err error
+	if w.gzip {
+		n, err = w.gz.Write(p)
+	} else {
+		n, err = w.file.Write(p)
+	}
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *rotatingWriter) closeCurrent() error {
+	if w.gzip {
+		if err := w.gz.Close(); err != nil {
+			return err
+		}
+	}
+	return w.file.Close()
+}
+
+func (w *rotatingWriter) Close() error {
+	return w.closeCurrent()
+}
+
+// parseSize parses a human-readable byte size such as "100MB" or "1GB".
+// A bare number is interpreted as bytes. An empty string returns 0, meaning
+// "no rotation".
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+	for _, unit := range units {
+		if strings.HasSuffix(strings.ToUpper(s), unit.suffix) {
+			numeric := s[:len(s)-len(unit.suffix)]
+			n, err := strconv.ParseInt(strings.TrimSpace(numeric), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid size %q: %w", s, err)
+			}
+			return n * unit.multiplier, nil
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	return n, nil
+}
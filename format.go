@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parsedLine is the normalized result of a LineFormatter successfully
+// parsing a raw log line.
+type parsedLine struct {
+	Time    time.Time
+	Level   string
+	Message string
+}
+
+// LineFormatter knows how to recognize and parse one structured-logging
+// schema out of a raw log line.
+type LineFormatter interface {
+	// Name identifies the formatter, and is the value accepted by the
+	// -format flag.
+	Name() string
+	// Parse attempts to interpret raw as this formatter's schema. ok is
+	// false if raw doesn't look like this schema at all.
+	Parse(raw string) (line parsedLine, ok bool)
+}
+
+// rawLineFormatter never parses; it's used for -format=raw and as the
+// fallback when auto-detection can't identify a schema.
+type rawLineFormatter struct{}
+
+func (rawLineFormatter) Name() string                    { return "raw" }
+func (rawLineFormatter) Parse(string) (parsedLine, bool) { return parsedLine{}, false }
+
+// jsonLineFormatter parses nomadlogs' original schema:
+// {"level":..., "time":..., "message":..., "trace.id":...}.
+type jsonLineFormatter struct{}
+
+func (jsonLineFormatter) Name() string { return "json" }
+
+func (jsonLineFormatter) Parse(raw string) (parsedLine, bool) {
+	var v struct {
+		Level   string    `json:"level"`
+		Time    time.Time `json:"time"`
+		Message string    `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil || v.Level == "" || v.Message == "" {
+		return parsedLine{}, false
+	}
+	return parsedLine{Time: v.Time, Level: v.Level, Message: v.Message}, true
+}
+
+// zapLineFormatter parses uber-go/zap's default JSON encoder output:
+// {"level":..., "ts":<unix seconds>, "msg":..., "caller":...}.
+type zapLineFormatter struct{}
+
+func (zapLineFormatter) Name() string { return "zap" }
+
+func (zapLineFormatter) Parse(raw string) (parsedLine, bool) {
+	var v struct {
+		Level string          `json:"level"`
+		TS    json.RawMessage `json:"ts"`
+		Msg   string          `json:"msg"`
+	}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil || v.Level == "" || v.Msg == "" || len(v.TS) == 0 {
+		return parsedLine{}, false
+	}
+	return parsedLine{Time: parseZapTimestamp(v.TS), Level: v.Level, Message: v.Msg}, true
+}
+
+func parseZapTimestamp(raw json.RawMessage) time.Time {
+	if seconds, err := strconv.ParseFloat(string(raw), 64); err == nil {
+		return time.Unix(0, int64(seconds*float64(time.Second)))
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// logrusLineFormatter parses sirupsen/logrus' default JSON formatter output:
+// {"level":..., "time":..., "msg":...}.
+type logrusLineFormatter struct{}
+
+func (logrusLineFormatter) Name() string { return "logrus" }
+
+func (logrusLineFormatter) Parse(raw string) (parsedLine, bool) {
+	var v struct {
+		Level string          `json:"level"`
+		Time  json.RawMessage `json:"time"`
+		Msg   string          `json:"msg"`
+	}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil || v.Level == "" || v.Msg == "" || len(v.Time) == 0 {
+		return parsedLine{}, false
+	}
+	var t time.Time
+	_ = json.Unmarshal(v.Time, &t)
+	return parsedLine{Time: t, Level: v.Level, Message: v.Msg}, true
+}
+
+// hclogLineFormatter parses hashicorp/go-hclog's JSON output:
+// {"@level":..., "@timestamp":..., "@message":...}.
+type hclogLineFormatter struct{}
+
+func (hclogLineFormatter) Name() string { return "hclog" }
+
+func (hclogLineFormatter) Parse(raw string) (parsedLine, bool) {
+	var v struct {
+		Level     string    `json:"@level"`
+		Timestamp time.Time `json:"@timestamp"`
+		Message   string    `json:"@message"`
+	}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil || v.Level == "" || v.Message == "" {
+		return parsedLine{}, false
+	}
+	return parsedLine{Time: v.Timestamp, Level: v.Level, Message: v.Message}, true
+}
+
+// ecsLineFormatter parses Elastic Common Schema JSON output:
+// {"@timestamp":..., "log.level":..., "message":...}.
+type ecsLineFormatter struct{}
+
+func (ecsLineFormatter) Name() string { return "ecs" }
+
+func (ecsLineFormatter) Parse(raw string) (parsedLine, bool) {
+	var v struct {
+		Timestamp time.Time `json:"@timestamp"`
+		LogLevel  string    `json:"log.level"`
+		Message   string    `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil || v.LogLevel == "" || v.Message == "" {
+		return parsedLine{}, false
+	}
+	return parsedLine{Time: v.Timestamp, Level: v.LogLevel, Message: v.Message}, true
+}
+
+// formatterRegistry lists every structured formatter probed during
+// auto-detection, in order of preference.
+var formatterRegistry = []LineFormatter{
+	jsonLineFormatter{},
+	hclogLineFormatter{},
+	ecsLineFormatter{},
+	zapLineFormatter{},
+	logrusLineFormatter{},
+}
+
+// formatterByName returns the formatter named by the -format flag, or nil
+// if name is "auto" (meaning: probe formatterRegistry per allocation).
+func formatterByName(name string) LineFormatter {
+	if name == "raw" {
+		return rawLineFormatter{}
+	}
+	for _, f := range formatterRegistry {
+		if f.Name() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// logLevelOrder ranks log levels from least to most severe, for -min-level
+// filtering. Unknown levels are left out, so they never get filtered.
+var logLevelOrder = map[string]int{
+	"trace":   0,
+	"debug":   1,
+	"info":    2,
+	"warn":    3,
+	"warning": 3,
+	"error":   4,
+	"fatal":   5,
+	"panic":   5,
+}
+
+// levelAtLeast reports whether level meets or exceeds min. An unrecognized
+// level always passes, since there's nothing sensible to filter on.
+func levelAtLeast(level, min string) bool {
+	minOrder, ok := logLevelOrder[strings.ToLower(min)]
+	if !ok {
+		return true
+	}
+	order, ok := logLevelOrder[strings.ToLower(level)]
+	if !ok {
+		return true
+	}
+	return order >= minOrder
+}
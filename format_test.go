@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatterParse(t *testing.T) {
+	cases := []struct {
+		name      string
+		formatter LineFormatter
+		raw       string
+		wantOK    bool
+		wantLine  parsedLine
+	}{
+		{
+			name:      "json",
+			formatter: jsonLineFormatter{},
+			raw:       `{"level":"info","time":"2021-07-01T00:00:00Z","message":"hello world"}`,
+			wantOK:    true,
+			wantLine:  parsedLine{Time: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC), Level: "info", Message: "hello world"},
+		},
+		{
+			name:      "json missing message",
+			formatter: jsonLineFormatter{},
+			raw:       `{"level":"info","time":"2021-07-01T00:00:00Z"}`,
+			wantOK:    false,
+		},
+		{
+			name:      "zap",
+			formatter: zapLineFormatter{},
+			raw:       `{"level":"info","ts":1625097600,"msg":"hello world"}`,
+			wantOK:    true,
+			wantLine:  parsedLine{Time: time.Unix(1625097600, 0), Level: "info", Message: "hello world"},
+		},
+		{
+			name:      "zap rejects logrus line",
+			formatter: zapLineFormatter{},
+			raw:       `{"level":"info","time":"2021-07-01T00:00:00Z","msg":"hello world"}`,
+			wantOK:    false,
+		},
+		{
+			name:      "logrus",
+			formatter: logrusLineFormatter{},
+			raw:       `{"level":"info","time":"2021-07-01T00:00:00Z","msg":"hello world"}`,
+			wantOK:    true,
+			wantLine:  parsedLine{Time: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC), Level: "info", Message: "hello world"},
+		},
+		{
+			name:      "logrus rejects zap line",
+			formatter: logrusLineFormatter{},
+			raw:       `{"level":"info","ts":1625097600,"msg":"hello world"}`,
+			wantOK:    false,
+		},
+		{
+			name:      "hclog",
+			formatter: hclogLineFormatter{},
+			raw:       `{"@level":"info","@timestamp":"2021-07-01T00:00:00Z","@message":"hello world"}`,
+			wantOK:    true,
+			wantLine:  parsedLine{Time: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC), Level: "info", Message: "hello world"},
+		},
+		{
+			name:      "ecs",
+			formatter: ecsLineFormatter{},
+			raw:       `{"@timestamp":"2021-07-01T00:00:00Z","log.level":"info","message":"hello world"}`,
+			wantOK:    true,
+			wantLine:  parsedLine{Time: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC), Level: "info", Message: "hello world"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			line, ok := c.formatter.Parse(c.raw)
+			if ok != c.wantOK {
+				t.Fatalf("Parse() ok = %v, want %v", ok, c.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if !line.Time.Equal(c.wantLine.Time) || line.Level != c.wantLine.Level || line.Message != c.wantLine.Message {
+				t.Fatalf("Parse() = %+v, want %+v", line, c.wantLine)
+			}
+		})
+	}
+}
+
+func TestAutoDetectPrecedence(t *testing.T) {
+	cases := []struct {
+		name     string
+		raw      string
+		wantName string
+		wantTime time.Time
+	}{
+		{
+			name:     "zap line is not misdetected as logrus",
+			raw:      `{"level":"info","ts":1625097600,"msg":"hello world"}`,
+			wantName: "zap",
+			wantTime: time.Unix(1625097600, 0),
+		},
+		{
+			name:     "logrus line is detected as logrus",
+			raw:      `{"level":"info","time":"2021-07-01T00:00:00Z","msg":"hello world"}`,
+			wantName: "logrus",
+			wantTime: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "nomadlogs json schema takes precedence",
+			raw:      `{"level":"info","time":"2021-07-01T00:00:00Z","message":"hello world"}`,
+			wantName: "json",
+			wantTime: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, f := range formatterRegistry {
+				line, ok := f.Parse(c.raw)
+				if !ok {
+					continue
+				}
+				if f.Name() != c.wantName {
+					t.Fatalf("auto-detect picked %q, want %q", f.Name(), c.wantName)
+				}
+				if !line.Time.Equal(c.wantTime) {
+					t.Fatalf("Time = %v, want %v", line.Time, c.wantTime)
+				}
+				return
+			}
+			t.Fatalf("no formatter in registry matched raw line")
+		})
+	}
+}